@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cloudfrontCannedPolicy is the JSON structure CloudFront expects for a
+// canned signed-cookie policy: blanket access to everything under Resource
+// until the DateLessThan condition passes.
+type cloudfrontCannedPolicy struct {
+	Statement []cloudfrontStatement `json:"Statement"`
+}
+
+type cloudfrontStatement struct {
+	Resource  string                  `json:"Resource"`
+	Condition cloudfrontStatementCond `json:"Condition"`
+}
+
+type cloudfrontStatementCond struct {
+	DateLessThan map[string]int64 `json:"DateLessThan"`
+}
+
+// signedCookieURLEncode applies CloudFront's URL-safe base64 variant:
+// '+' -> '-', '=' -> '_', '/' -> '~'.
+func signedCookieURLEncode(data []byte) string {
+	replacer := strings.NewReplacer("+", "-", "=", "_", "/", "~")
+	return replacer.Replace(base64.StdEncoding.EncodeToString(data))
+}
+
+// setCloudFrontSignedCookies sets the CloudFront-Policy/-Signature/-Key-Pair-Id
+// cookies that grant access to every resource under resourcePrefix (e.g. an
+// HLS master playlist and all its segments) until expireTime, so a single
+// playback session doesn't need every segment individually presigned.
+// cookieDomain must be the CDN's host (or a parent domain shared with it) --
+// the API's own host won't do, since these cookies need to be replayed back
+// to cfg.cdnBaseURL, not to the API.
+func setCloudFrontSignedCookies(w http.ResponseWriter, resourcePrefix, keyPairID string, privateKey *rsa.PrivateKey, expireTime time.Duration, cookieDomain string) error {
+	policy := cloudfrontCannedPolicy{
+		Statement: []cloudfrontStatement{{
+			Resource: resourcePrefix,
+			Condition: cloudfrontStatementCond{
+				DateLessThan: map[string]int64{"AWS:EpochTime": time.Now().Add(expireTime).Unix()},
+			},
+		}},
+	}
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal cloudfront policy: %w", err)
+	}
+
+	hash := sha1.Sum(policyJSON)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA1, hash[:])
+	if err != nil {
+		return fmt.Errorf("couldn't sign cloudfront policy: %w", err)
+	}
+
+	newCookie := func(name, value string) *http.Cookie {
+		return &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Domain:   cookieDomain,
+			Path:     "/",
+			Expires:  time.Now().Add(expireTime),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		}
+	}
+
+	http.SetCookie(w, newCookie("CloudFront-Policy", signedCookieURLEncode(policyJSON)))
+	http.SetCookie(w, newCookie("CloudFront-Signature", signedCookieURLEncode(signature)))
+	http.SetCookie(w, newCookie("CloudFront-Key-Pair-Id", keyPairID))
+	return nil
+}
+
+// parseCloudFrontPrivateKey loads the PEM-encoded RSA private key
+// downloaded for a CloudFront key pair.
+func parseCloudFrontPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("couldn't decode PEM block")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse cloudfront private key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// FileStore persists an uploaded asset under key and returns that same key
+// unchanged, for storage alongside a stable DB reference (e.g.
+// video.ThumbnailURL); SignURL resolves a stored key to a fetchable URL on
+// read, since backends like S3 only hand out time-limited presigned URLs.
+type FileStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	SignURL(ctx context.Context, key string, expireTime time.Duration) (string, error)
+	// Get opens a previously stored key for reading, e.g. to re-derive a
+	// thumbnail from an already-uploaded video. Callers must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// VideoFileStore is the FileStore used for videos, which stream in as one
+// big multipart upload instead of a single Put. r must support ReaderAt so
+// a multipart backend can stream parts off it in parallel; progress, if
+// non-nil, is updated as bytes are uploaded.
+type VideoFileStore interface {
+	FileStore
+	PutAndSignURL(ctx context.Context, key string, r io.ReaderAt, size int64, contentType string, expireTime time.Duration, progress *uploadProgress) (string, error)
+}
+
+// S3FileStore stores assets in an S3 bucket and serves them through
+// presigned GET URLs.
+type S3FileStore struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3FileStore(client *s3.Client, bucket string) *S3FileStore {
+	return &S3FileStore{client: client, bucket: bucket}
+}
+
+func (s *S3FileStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &s.bucket,
+		Key:         &key,
+		Body:        r,
+		ContentType: &contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't upload to s3: %w", err)
+	}
+	return key, nil
+}
+
+// PutAndSignURL streams r to the bucket via the S3 multipart API (so large
+// videos upload as parallel chunks straight from disk rather than one
+// buffered PutObject) and returns a presigned GET URL for the result.
+func (s *S3FileStore) PutAndSignURL(ctx context.Context, key string, r io.ReaderAt, size int64, contentType string, expireTime time.Duration, progress *uploadProgress) (string, error) {
+	if progress != nil {
+		progress.startPhase("uploading", size)
+	}
+	err := putMultipart(ctx, s.client, s.bucket, key, r, size, contentType, func(n int64) {
+		if progress != nil {
+			progress.add(n)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.SignURL(ctx, key, expireTime)
+}
+
+func (s *S3FileStore) SignURL(ctx context.Context, key string, expireTime time.Duration) (string, error) {
+	return generatePresignedURL(s.client, s.bucket, key, expireTime)
+}
+
+func (s *S3FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get %s from s3: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+// LocalFileStore writes assets to disk under assetsRoot and serves them
+// through the existing /assets/ static mount, so the server can run fully
+// offline for local development and tests.
+type LocalFileStore struct {
+	assetsRoot string
+	baseURL    string
+}
+
+func NewLocalFileStore(assetsRoot, baseURL string) *LocalFileStore {
+	return &LocalFileStore{assetsRoot: assetsRoot, baseURL: baseURL}
+}
+
+func (l *LocalFileStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	path := filepath.Join(l.assetsRoot, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("couldn't create asset directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return "", fmt.Errorf("couldn't copy file: %w", err)
+	}
+
+	return key, nil
+}
+
+func (l *LocalFileStore) PutAndSignURL(ctx context.Context, key string, r io.ReaderAt, size int64, contentType string, expireTime time.Duration, progress *uploadProgress) (string, error) {
+	if progress != nil {
+		progress.startPhase("uploading", size)
+	}
+	reader := io.NewSectionReader(r, 0, size)
+	wrapped := newProgressReader(reader, func(n int64) {
+		if progress != nil {
+			progress.add(n)
+		}
+	})
+	if _, err := l.Put(ctx, key, wrapped, contentType); err != nil {
+		return "", err
+	}
+	return l.SignURL(ctx, key, expireTime)
+}
+
+func (l *LocalFileStore) SignURL(ctx context.Context, key string, expireTime time.Duration) (string, error) {
+	return fmt.Sprintf("%s/%s", l.baseURL, key), nil
+}
+
+func (l *LocalFileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(l.assetsRoot, key))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open %s: %w", key, err)
+	}
+	return file, nil
+}
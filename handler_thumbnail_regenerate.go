@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/google/uuid"
+)
+
+// handlerRegenerateThumbnail backs POST /api/videos/{videoID}/thumbnail/regenerate,
+// letting a user re-pick the poster frame after the automatic one from
+// handlerUploadVideo wasn't a good fit. ?time= takes an ffmpeg -ss value
+// (e.g. "00:00:07" or "7.5"); it defaults to one second in.
+func (cfg *apiConfig) handlerRegenerateThumbnail(w http.ResponseWriter, r *http.Request) {
+	videoIDString := r.PathValue("videoID")
+	videoID, err := uuid.Parse(videoIDString)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusUnauthorized, "You can't regenerate a thumbnail for this video", err)
+		return
+	}
+	if video.VideoURL == nil {
+		respondWithError(w, http.StatusBadRequest, "Video hasn't finished uploading yet", nil)
+		return
+	}
+
+	timeOffset := r.URL.Query().Get("time")
+	if timeOffset == "" {
+		timeOffset = "00:00:01"
+	}
+
+	videoReader, err := cfg.videoStore.Get(r.Context(), *video.VideoURL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch stored video", err)
+		return
+	}
+	defer videoReader.Close()
+
+	sourceFile, err := os.CreateTemp("", "tubely-regenerate-*.mp4")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create temp file", err)
+		return
+	}
+	defer os.Remove(sourceFile.Name())
+	defer sourceFile.Close()
+
+	if _, err := io.Copy(sourceFile, videoReader); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't copy video", err)
+		return
+	}
+
+	thumbnailKey, err := generateThumbnailFromSource(r.Context(), cfg, videoID, sourceFile.Name(), timeOffset)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't generate thumbnail", err)
+		return
+	}
+	video.ThumbnailURL = &thumbnailKey
+
+	err = cfg.db.UpdateVideo(video)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
+		return
+	}
+
+	signedVideo, err := cfg.dbVideoToSignedVideo(w, video)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign video", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, signedVideo)
+}
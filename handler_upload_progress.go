@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/google/uuid"
+)
+
+// handlerGetUploadProgress backs GET /api/videos/{videoID}/upload_progress,
+// polled by the frontend to drive a progress bar while handlerUploadVideo
+// is still streaming and storing the file.
+func (cfg *apiConfig) handlerGetUploadProgress(w http.ResponseWriter, r *http.Request) {
+	videoIDString := r.PathValue("videoID")
+	videoID, err := uuid.Parse(videoIDString)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't get video", err)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusUnauthorized, "You can't view upload progress for this video", err)
+		return
+	}
+
+	progress, ok := cfg.uploadProgress.get(videoID)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "No upload in progress for this video", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, progress.snapshot())
+}
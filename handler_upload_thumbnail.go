@@ -4,11 +4,8 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
 	"github.com/google/uuid"
@@ -72,34 +69,42 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	detected, fileContents, err := sniffContentType(formFile)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sniff file contents", err)
+		return
+	}
+	if sniffedMediaType(detected) != mediaType {
+		respondWithError(w, http.StatusBadRequest, "File contents don't match declared type", nil)
+		return
+	}
+
 	randomBytes := make([]byte, 32)
 	rand.Read(randomBytes)
 	randomString := base64.RawURLEncoding.EncodeToString(randomBytes)
 	extension := getExtension(mediaType)
-	newfilePath := filepath.Join(cfg.assetsRoot, randomString + "." + extension)
-	file, err := os.Create(newfilePath)
+	key := fmt.Sprintf("%s.%s", randomString, extension)
+
+	thumbnailKey, err := cfg.thumbnailStore.Put(r.Context(), key, fileContents, mediaType)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create file", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store thumbnail", err)
 		return
 	}
-	defer file.Close()
+	video.ThumbnailURL = &thumbnailKey
 
-	_, err = io.Copy(file, formFile)
+	err = cfg.db.UpdateVideo(video)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't copy file", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
 		return
 	}
 
-	thumbnailURL := fmt.Sprintf("http://localhost:%s/assets/%s.%s", cfg.port, randomString, extension)
-	video.ThumbnailURL = &thumbnailURL
-
-	err = cfg.db.UpdateVideo(video)
+	signedVideo, err := cfg.dbVideoToSignedVideo(w, video)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign video", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, video)
+	respondWithJSON(w, http.StatusOK, signedVideo)
 }
 
 func getExtension(contentType string) string {
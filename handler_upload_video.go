@@ -13,7 +13,6 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -54,7 +53,9 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 
 	fmt.Println("uploading video", videoID, "by user", userID)
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1 << 30)
+	progress := cfg.uploadProgress.start(videoID)
+
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.maxUploadBytes)
 	formFile, formFileHeader, err := r.FormFile("video")
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Couldn't parse multipart form", err)
@@ -73,6 +74,16 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	detected, fileContents, err := sniffContentType(formFile)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sniff file contents", err)
+		return
+	}
+	if sniffedMediaType(detected) != mediaType {
+		respondWithError(w, http.StatusBadRequest, "File contents don't match declared type", nil)
+		return
+	}
+
 	tempFile, err := os.CreateTemp("", "tubely-upload.mp4")
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create temp file", err)
@@ -81,8 +92,11 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	defer os.Remove(tempFile.Name())
 	defer tempFile.Close()
 
-	_, err = io.Copy(tempFile, formFile)
+	progress.startPhase("receiving", formFileHeader.Size)
+	receivedFile := newProgressReader(fileContents, progress.add)
+	_, err = io.Copy(tempFile, receivedFile)
 	if err != nil {
+		cfg.uploadProgress.finish(videoID, err)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't copy file", err)
 		return
 	}
@@ -92,6 +106,15 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := validateVideoStreams(tempFile.Name(), cfg.maxVideoDuration); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Video failed validation", err)
+		return
+	}
+	if err := scanForMalware(cfg.clamscanPath, tempFile.Name()); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Video failed malware scan", err)
+		return
+	}
+
 	fastStartTempFilepath, err := processVideoForFastStart(tempFile.Name())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't process video", err)
@@ -102,9 +125,17 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't open fast start temp file", err)
 		return
 	}
-	defer os.Remove(fastStartTempFilepath)
 	defer fastTempFile.Close()
 
+	// Once the hls queue picks this up it owns deletion of
+	// fastStartTempFilepath; until then we clean up on any early return.
+	hlsQueued := false
+	defer func() {
+		if !hlsQueued {
+			os.Remove(fastStartTempFilepath)
+		}
+	}()
+
 	aspectRatio, err := getVideoAspectRatio(tempFile.Name())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't get video aspect ratio", err)
@@ -116,27 +147,51 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	extension := getExtension(mediaType)
 	fileKey := fmt.Sprintf("%s/%s.%s", aspectRatio, randomString, extension)
 
-	_, err = cfg.s3Client.PutObject(r.Context(), &s3.PutObjectInput{
-		Bucket: &cfg.s3Bucket,
-		Key:    &fileKey,
-		Body:   fastTempFile,
-		ContentType: &mediaType,
-	})
+	fastStat, err := fastTempFile.Stat()
+	if err != nil {
+		cfg.uploadProgress.finish(videoID, err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't stat processed video", err)
+		return
+	}
+
+	_, err = cfg.videoStore.PutAndSignURL(r.Context(), fileKey, fastTempFile, fastStat.Size(), mediaType, 10*time.Minute, progress)
 	if err != nil {
+		cfg.uploadProgress.finish(videoID, err)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't upload file", err)
 		return
 	}
+	cfg.uploadProgress.finish(videoID, nil)
 
-	videoURL := fmt.Sprintf("%s,%s", cfg.s3Bucket, fileKey)
-	video.VideoURL = &videoURL
+	if video.ThumbnailURL == nil {
+		duration, err := getVideoDuration(tempFile.Name())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get video duration", err)
+			return
+		}
+		posterTime := fmt.Sprintf("%f", duration*0.1)
+		thumbnailKey, err := generateThumbnailFromSource(r.Context(), cfg, videoID, tempFile.Name(), posterTime)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't generate thumbnail", err)
+			return
+		}
+		video.ThumbnailURL = &thumbnailKey
+	}
 
+	video.VideoURL = &fileKey
 	err = cfg.db.UpdateVideo(video)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't update video", err)
 		return
 	}
 
-	signedVideo, err := cfg.dbVideoToSignedVideo(video)
+	hlsQueued = true
+	cfg.hlsQueue.enqueue(hlsJob{
+		videoID:     videoID,
+		sourcePath:  fastStartTempFilepath,
+		aspectRatio: aspectRatio,
+	})
+
+	signedVideo, err := cfg.dbVideoToSignedVideo(w, video)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't sign video", err)
 		return
@@ -145,24 +200,73 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusOK, signedVideo)
 }
 
-func (cfg *apiConfig) dbVideoToSignedVideo(video database.Video) (database.Video, error) {
-	if video.VideoURL == nil {
+// dbVideoToSignedVideo turns the keys stored on video into URLs a client can
+// fetch. Public videos get a stable CDN URL with nothing to sign. Private
+// and unlisted videos either get a short-lived presigned URL, or, when
+// cfg.useSignedCookies is set, CloudFront signed cookies covering the whole
+// video (including every HLS segment under it) plus the plain CDN URL --
+// this avoids presigning hundreds of segment URLs for one playback session.
+// w may be nil when no signed cookies are needed (e.g. public videos).
+func (cfg *apiConfig) dbVideoToSignedVideo(w http.ResponseWriter, video database.Video) (database.Video, error) {
+	if video.Visibility == database.VideoVisibilityPublic {
+		video.VideoURL = cdnURL(cfg.cdnBaseURL, video.VideoURL)
+		video.HLSMasterURL = cdnURL(cfg.cdnBaseURL, video.HLSMasterURL)
+		video.ThumbnailURL = cdnURL(cfg.cdnBaseURL, video.ThumbnailURL)
 		return video, nil
 	}
-	
-	params := strings.Split(*video.VideoURL, ",")
-	bucket := params[0]
-	key := params[1]
 
-	url, err := generatePresignedURL(cfg.s3Client, bucket, key, 10*time.Minute)
-	if err != nil {
-		return database.Video{}, err
+	if cfg.useSignedCookies {
+		if w == nil {
+			return database.Video{}, fmt.Errorf("signed cookies require a response writer")
+		}
+		resourcePrefix := fmt.Sprintf("%s/*", cfg.cdnBaseURL)
+		err := setCloudFrontSignedCookies(w, resourcePrefix, cfg.cloudfrontKeyPairID, cfg.cloudfrontPrivateKey, 10*time.Minute, cfg.cloudfrontCookieDomain)
+		if err != nil {
+			return database.Video{}, err
+		}
+		video.VideoURL = cdnURL(cfg.cdnBaseURL, video.VideoURL)
+		video.HLSMasterURL = cdnURL(cfg.cdnBaseURL, video.HLSMasterURL)
+		video.ThumbnailURL = cdnURL(cfg.cdnBaseURL, video.ThumbnailURL)
+		return video, nil
+	}
+
+	if video.VideoURL != nil {
+		url, err := cfg.videoStore.SignURL(context.Background(), *video.VideoURL, 10*time.Minute)
+		if err != nil {
+			return database.Video{}, err
+		}
+		video.VideoURL = &url
+	}
+	if video.HLSMasterURL != nil {
+		// The child playlists a signed master points at aren't presigned
+		// themselves, so adaptive streaming over plain presigned URLs only
+		// works end-to-end once cfg.useSignedCookies covers the segments too.
+		url, err := cfg.videoStore.SignURL(context.Background(), *video.HLSMasterURL, 10*time.Minute)
+		if err != nil {
+			return database.Video{}, err
+		}
+		video.HLSMasterURL = &url
+	}
+	if video.ThumbnailURL != nil {
+		url, err := cfg.thumbnailStore.SignURL(context.Background(), *video.ThumbnailURL, 10*time.Minute)
+		if err != nil {
+			return database.Video{}, err
+		}
+		video.ThumbnailURL = &url
 	}
 
-	video.VideoURL = &url
 	return video, nil
 }
 
+// cdnURL rewrites a stored key into a stable CDN URL, leaving a nil key nil.
+func cdnURL(cdnBaseURL string, key *string) *string {
+	if key == nil {
+		return nil
+	}
+	url := fmt.Sprintf("%s/%s", cdnBaseURL, *key)
+	return &url
+}
+
 func getVideoAspectRatio(filePath string) (string, error) {
 	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json", "-show_streams", filePath)
 	stdoutBuffer := bytes.Buffer{}
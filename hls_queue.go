@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// hlsJob asks the transcode worker pool to build an HLS rendition ladder
+// for a video whose faststart MP4 has already been uploaded. sourcePath is
+// owned by the job: the worker removes it once transcoding is done.
+type hlsJob struct {
+	videoID     uuid.UUID
+	sourcePath  string
+	aspectRatio string
+}
+
+// hlsQueue runs hlsJobs on a small pool of background workers so
+// handlerUploadVideo can respond as soon as the source upload completes,
+// instead of blocking the HTTP request on a multi-minute ffmpeg pipeline.
+type hlsQueue struct {
+	cfg  *apiConfig
+	jobs chan hlsJob
+}
+
+func newHLSQueue(cfg *apiConfig, workers int) *hlsQueue {
+	q := &hlsQueue{cfg: cfg, jobs: make(chan hlsJob, 16)}
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+func (q *hlsQueue) enqueue(job hlsJob) {
+	q.jobs <- job
+}
+
+func (q *hlsQueue) worker() {
+	for job := range q.jobs {
+		if err := q.process(job); err != nil {
+			log.Printf("hls transcode failed for video %s: %v", job.videoID, err)
+		}
+		os.Remove(job.sourcePath)
+	}
+}
+
+func (q *hlsQueue) process(job hlsJob) error {
+	ctx := context.Background()
+
+	outputDir, err := transcodeToHLS(job.sourcePath)
+	if err != nil {
+		return fmt.Errorf("couldn't transcode to hls: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	keyPrefix := fmt.Sprintf("%s/%s/hls", job.aspectRatio, job.videoID)
+	masterKey, err := uploadHLSOutput(ctx, q.cfg.videoStore, outputDir, keyPrefix)
+	if err != nil {
+		return fmt.Errorf("couldn't upload hls output: %w", err)
+	}
+
+	video, err := q.cfg.db.GetVideo(job.videoID)
+	if err != nil {
+		return fmt.Errorf("couldn't reload video: %w", err)
+	}
+	video.HLSMasterURL = &masterKey
+	if err := q.cfg.db.UpdateVideo(video); err != nil {
+		return fmt.Errorf("couldn't update video with hls master: %w", err)
+	}
+	return nil
+}
+
+// uploadHLSOutput uploads every file transcodeToHLS wrote to dir under
+// keyPrefix and returns the key of the master playlist.
+func uploadHLSOutput(ctx context.Context, store VideoFileStore, dir, keyPrefix string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	masterKey := ""
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+
+		key := fmt.Sprintf("%s/%s", keyPrefix, name)
+		_, err = store.Put(ctx, key, file, contentTypeForHLSFile(name))
+		file.Close()
+		if err != nil {
+			return "", fmt.Errorf("couldn't upload %s: %w", name, err)
+		}
+		if name == "master.m3u8" {
+			masterKey = key
+		}
+	}
+	if masterKey == "" {
+		return "", fmt.Errorf("master playlist missing from hls output")
+	}
+	return masterKey, nil
+}
+
+func contentTypeForHLSFile(name string) string {
+	switch filepath.Ext(name) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	default:
+		return "application/octet-stream"
+	}
+}
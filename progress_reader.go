@@ -0,0 +1,25 @@
+package main
+
+import "io"
+
+// progressReader wraps an io.Reader and reports the number of bytes each
+// Read returned to onRead as the stream is consumed, so long-running copies
+// (the uploaded form file, a LocalFileStore write) can surface progress.
+// S3 multipart part uploads do not go through it: putMultipart reports each
+// part's size once that part has finished uploading.
+type progressReader struct {
+	r      io.Reader
+	onRead func(n int64)
+}
+
+func newProgressReader(r io.Reader, onRead func(n int64)) *progressReader {
+	return &progressReader{r: r, onRead: onRead}
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 && p.onRead != nil {
+		p.onRead(int64(n))
+	}
+	return n, err
+}
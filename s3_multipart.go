@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// multipartPartSize is the chunk size streamed per UploadPart call.
+const multipartPartSize = 16 << 20 // 16 MiB
+
+// maxConcurrentParts bounds how many parts of one upload are in flight at once.
+const maxConcurrentParts = 4
+
+// putMultipart uploads the size bytes readable through r to bucket/key via
+// the S3 multipart API, streaming parts directly off r (typically the
+// on-disk temp file) in parallel instead of buffering the whole object in
+// memory. onProgress is called with the size of each part as it finishes.
+func putMultipart(ctx context.Context, client *s3.Client, bucket, key string, r io.ReaderAt, size int64, contentType string, onProgress func(n int64)) error {
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      &bucket,
+		Key:         &key,
+		ContentType: &contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create multipart upload: %w", err)
+	}
+	uploadID := created.UploadId
+
+	numParts := int((size + multipartPartSize - 1) / multipartPartSize)
+	parts := make([]types.CompletedPart, numParts)
+	errs := make([]error, numParts)
+
+	sem := make(chan struct{}, maxConcurrentParts)
+	var wg sync.WaitGroup
+	for i := 0; i < numParts; i++ {
+		offset := int64(i) * multipartPartSize
+		partSize := int64(multipartPartSize)
+		if remaining := size - offset; remaining < partSize {
+			partSize = remaining
+		}
+		partNumber := int32(i + 1)
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, offset, partSize int64, partNumber int32) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			uploaded, err := client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:     &bucket,
+				Key:        &key,
+				PartNumber: aws.Int32(partNumber),
+				UploadId:   uploadID,
+				Body:       io.NewSectionReader(r, offset, partSize),
+			})
+			if err != nil {
+				errs[idx] = fmt.Errorf("couldn't upload part %d: %w", partNumber, err)
+				return
+			}
+			parts[idx] = types.CompletedPart{ETag: uploaded.ETag, PartNumber: aws.Int32(partNumber)}
+			if onProgress != nil {
+				onProgress(partSize)
+			}
+		}(i, offset, partSize, partNumber)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			_, _ = client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   &bucket,
+				Key:      &key,
+				UploadId: uploadID,
+			})
+			return err
+		}
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &bucket,
+		Key:             &key,
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't complete multipart upload: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/google/uuid"
+)
+
+// thumbnailWidth and thumbnailHeight match the 16:9 poster size used
+// throughout the frontend's video grid.
+const (
+	thumbnailWidth  = 177
+	thumbnailHeight = 100
+)
+
+// getVideoDuration returns sourcePath's duration in seconds via ffprobe.
+func getVideoDuration(sourcePath string) (float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json", "-show_format", sourcePath)
+	stdoutBuffer := bytes.Buffer{}
+	cmd.Stdout = &stdoutBuffer
+	if err := cmd.Run(); err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(stdoutBuffer.Bytes(), &result); err != nil {
+		return 0, err
+	}
+
+	var duration float64
+	if _, err := fmt.Sscanf(result.Format.Duration, "%f", &duration); err != nil {
+		return 0, fmt.Errorf("couldn't parse duration %q: %w", result.Format.Duration, err)
+	}
+	return duration, nil
+}
+
+// extractThumbnailFrame pulls a single JPEG frame from sourcePath at
+// timeOffset (an ffmpeg -ss value, e.g. "00:00:07" or "7.5") and writes it
+// to a new temp file, whose path it returns.
+func extractThumbnailFrame(sourcePath, timeOffset string) (string, error) {
+	outputFile, err := os.CreateTemp("", "tubely-thumbnail-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	outputPath := outputFile.Name()
+	outputFile.Close()
+
+	cmd := exec.Command("ffmpeg", "-y", "-ss", timeOffset, "-i", sourcePath,
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:%d", thumbnailWidth, thumbnailHeight),
+		"-f", "image2", outputPath)
+	if err := cmd.Run(); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("couldn't extract thumbnail frame: %w", err)
+	}
+	return outputPath, nil
+}
+
+// generateThumbnailFromSource extracts a poster frame at timeOffset from
+// sourcePath, uploads it through cfg.thumbnailStore under "<videoID>.jpg"
+// (overwriting any thumbnail generated earlier for the same video), and
+// returns that key. The key, not a URL, is what gets persisted on
+// video.ThumbnailURL -- cfg.dbVideoToSignedVideo resolves it to a fetchable
+// URL on read, the same way it does for video.VideoURL.
+func generateThumbnailFromSource(ctx context.Context, cfg *apiConfig, videoID uuid.UUID, sourcePath, timeOffset string) (string, error) {
+	framePath, err := extractThumbnailFrame(sourcePath, timeOffset)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(framePath)
+
+	frame, err := os.Open(framePath)
+	if err != nil {
+		return "", fmt.Errorf("couldn't open extracted thumbnail: %w", err)
+	}
+	defer frame.Close()
+
+	key := fmt.Sprintf("%s.jpg", videoID)
+	thumbnailKey, err := cfg.thumbnailStore.Put(ctx, key, frame, "image/jpeg")
+	if err != nil {
+		return "", fmt.Errorf("couldn't store thumbnail: %w", err)
+	}
+	return thumbnailKey, nil
+}
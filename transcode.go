@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hlsRendition describes one rung of the adaptive bitrate ladder produced
+// by transcodeToHLS.
+type hlsRendition struct {
+	name         string // also the output file's basename, e.g. "720p"
+	width        int
+	height       int
+	videoBitrate string // ffmpeg -b:v value, e.g. "2800k"
+	audioBitrate string // ffmpeg -b:a value, e.g. "128k"
+}
+
+var hlsLadder = []hlsRendition{
+	{name: "1080p", width: 1920, height: 1080, videoBitrate: "5000k", audioBitrate: "192k"},
+	{name: "720p", width: 1280, height: 720, videoBitrate: "2800k", audioBitrate: "128k"},
+	{name: "480p", width: 854, height: 480, videoBitrate: "1400k", audioBitrate: "128k"},
+	{name: "360p", width: 640, height: 360, videoBitrate: "800k", audioBitrate: "96k"},
+}
+
+// transcodeToHLS segments the faststart MP4 at sourcePath into the
+// renditions in hlsLadder plus a master playlist, all written to a fresh
+// temp directory whose path it returns. The caller removes that directory
+// once its contents have been uploaded.
+func transcodeToHLS(sourcePath string) (string, error) {
+	outputDir, err := os.MkdirTemp("", "tubely-hls")
+	if err != nil {
+		return "", err
+	}
+
+	masterLines := []string{"#EXTM3U", "#EXT-X-VERSION:3"}
+
+	for _, rendition := range hlsLadder {
+		playlistName := rendition.name + ".m3u8"
+		segmentPattern := rendition.name + "_%03d.ts"
+
+		cmd := exec.Command("ffmpeg", "-i", sourcePath,
+			"-vf", fmt.Sprintf("scale=%d:%d", rendition.width, rendition.height),
+			"-c:v", "libx264", "-b:v", rendition.videoBitrate,
+			"-c:a", "aac", "-b:a", rendition.audioBitrate,
+			"-hls_time", "6", "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(outputDir, segmentPattern),
+			"-f", "hls", filepath.Join(outputDir, playlistName),
+		)
+		if err := cmd.Run(); err != nil {
+			os.RemoveAll(outputDir)
+			return "", fmt.Errorf("couldn't transcode %s rendition: %w", rendition.name, err)
+		}
+
+		bandwidth := bitrateToBps(rendition.videoBitrate) + bitrateToBps(rendition.audioBitrate)
+		masterLines = append(masterLines,
+			fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d", bandwidth, rendition.width, rendition.height),
+			playlistName,
+		)
+	}
+
+	masterPath := filepath.Join(outputDir, "master.m3u8")
+	if err := os.WriteFile(masterPath, []byte(strings.Join(masterLines, "\n")+"\n"), 0644); err != nil {
+		os.RemoveAll(outputDir)
+		return "", fmt.Errorf("couldn't write master playlist: %w", err)
+	}
+
+	return outputDir, nil
+}
+
+// bitrateToBps parses an ffmpeg-style bitrate like "5000k" into bits/sec,
+// for the BANDWIDTH attribute HLS clients use to pick a rendition.
+func bitrateToBps(bitrate string) int {
+	n, err := strconv.Atoi(strings.TrimSuffix(bitrate, "k"))
+	if err != nil {
+		return 0
+	}
+	return n * 1000
+}
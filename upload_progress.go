@@ -0,0 +1,126 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// uploadProgressRetention is how long a finished upload's progress stays
+// queryable before the tracker reclaims it, so byVideo doesn't grow by one
+// entry for every video ever uploaded over the life of the process.
+const uploadProgressRetention = 5 * time.Minute
+
+// uploadProgress tracks byte counters for a single in-flight video upload
+// so that GET /api/videos/{videoID}/upload_progress can report how far
+// along it is. It moves through phases (receiving the upload, uploading to
+// the file store) and resets its counters at the start of each one.
+type uploadProgress struct {
+	mu         sync.RWMutex
+	phase      string
+	bytesDone  int64
+	bytesTotal int64
+	done       bool
+	err        string
+}
+
+func (p *uploadProgress) startPhase(phase string, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase = phase
+	p.bytesDone = 0
+	p.bytesTotal = total
+}
+
+func (p *uploadProgress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytesDone += n
+}
+
+func (p *uploadProgress) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = true
+	if err != nil {
+		p.err = err.Error()
+	}
+}
+
+type uploadProgressSnapshot struct {
+	Phase      string  `json:"phase"`
+	BytesDone  int64   `json:"bytesDone"`
+	BytesTotal int64   `json:"bytesTotal"`
+	Percent    float64 `json:"percent"`
+	Done       bool    `json:"done"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func (p *uploadProgress) snapshot() uploadProgressSnapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var percent float64
+	if p.bytesTotal > 0 {
+		percent = float64(p.bytesDone) / float64(p.bytesTotal) * 100
+	}
+	return uploadProgressSnapshot{
+		Phase:      p.phase,
+		BytesDone:  p.bytesDone,
+		BytesTotal: p.bytesTotal,
+		Percent:    percent,
+		Done:       p.done,
+		Error:      p.err,
+	}
+}
+
+// uploadProgressTracker holds one uploadProgress per in-flight video
+// upload, keyed by video ID, so the progress handler can look it up
+// without threading state through the request context.
+type uploadProgressTracker struct {
+	mu      sync.Mutex
+	byVideo map[uuid.UUID]*uploadProgress
+}
+
+func newUploadProgressTracker() *uploadProgressTracker {
+	return &uploadProgressTracker{byVideo: make(map[uuid.UUID]*uploadProgress)}
+}
+
+func (t *uploadProgressTracker) start(videoID uuid.UUID) *uploadProgress {
+	p := &uploadProgress{}
+	t.mu.Lock()
+	t.byVideo[videoID] = p
+	t.mu.Unlock()
+	return p
+}
+
+func (t *uploadProgressTracker) get(videoID uuid.UUID) (*uploadProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.byVideo[videoID]
+	return p, ok
+}
+
+// finish marks videoID's progress done and schedules its removal from
+// byVideo after uploadProgressRetention, once pollers have had a chance to
+// observe the final state.
+func (t *uploadProgressTracker) finish(videoID uuid.UUID, err error) {
+	t.mu.Lock()
+	p, ok := t.byVideo[videoID]
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	p.finish(err)
+	time.AfterFunc(uploadProgressRetention, func() {
+		t.mu.Lock()
+		// Only reclaim the entry this finish call was for -- if videoID was
+		// re-uploaded within the retention window, byVideo now holds a
+		// different, possibly still in-flight, *uploadProgress.
+		if t.byVideo[videoID] == p {
+			delete(t.byVideo, videoID)
+		}
+		t.mu.Unlock()
+	})
+}
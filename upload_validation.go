@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const sniffBufferSize = 512
+
+// sniffContentType reads up to sniffBufferSize bytes from r to detect its
+// real MIME type via the content-sniffing algorithm in net/http, rather
+// than trusting the client-supplied Content-Type header. It returns a
+// reader that replays those bytes ahead of the rest of r, so callers can
+// sniff without losing data they still need to copy out.
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	buf := make([]byte, sniffBufferSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, fmt.Errorf("couldn't read file for sniffing: %w", err)
+	}
+	buf = buf[:n]
+	detected := http.DetectContentType(buf)
+	return detected, io.MultiReader(bytes.NewReader(buf), r), nil
+}
+
+// sniffedMediaType strips the parameters DetectContentType appends (e.g.
+// "text/plain; charset=utf-8") so callers can compare bare MIME types.
+func sniffedMediaType(detected string) string {
+	return strings.TrimSpace(strings.SplitN(detected, ";", 2)[0])
+}
+
+type ffprobeStreamInfo struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// validateVideoStreams runs ffprobe on sourcePath and rejects anything that
+// isn't an H.264/AAC video with at least one video stream and a duration
+// of at most maxDuration, so malformed or overlong uploads never reach the
+// faststart/transcode/store steps. It does not look for HTML or SVG content
+// embedded in the file.
+func validateVideoStreams(sourcePath string, maxDuration time.Duration) error {
+	cmd := exec.Command("ffprobe", "-v", "error", "-print_format", "json", "-show_streams", "-show_format", sourcePath)
+	stdout := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("couldn't probe video: %w", err)
+	}
+
+	var info ffprobeStreamInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		return fmt.Errorf("couldn't parse ffprobe output: %w", err)
+	}
+
+	hasVideo := false
+	for _, stream := range info.Streams {
+		switch stream.CodecType {
+		case "video":
+			hasVideo = true
+			if stream.CodecName != "h264" {
+				return fmt.Errorf("unsupported video codec %q", stream.CodecName)
+			}
+		case "audio":
+			if stream.CodecName != "aac" {
+				return fmt.Errorf("unsupported audio codec %q", stream.CodecName)
+			}
+		}
+	}
+	if !hasVideo {
+		return fmt.Errorf("no video stream found")
+	}
+
+	var durationSeconds float64
+	if _, err := fmt.Sscanf(info.Format.Duration, "%f", &durationSeconds); err != nil {
+		return fmt.Errorf("couldn't parse duration %q: %w", info.Format.Duration, err)
+	}
+	if duration := time.Duration(durationSeconds * float64(time.Second)); duration > maxDuration {
+		return fmt.Errorf("video duration %s exceeds the %s limit", duration, maxDuration)
+	}
+
+	return nil
+}
+
+// scanForMalware shells out to clamscanPath (e.g. clamscan) and rejects
+// sourcePath if it exits non-zero, i.e. flags it as infected. An empty
+// clamscanPath disables scanning.
+func scanForMalware(clamscanPath, sourcePath string) error {
+	if clamscanPath == "" {
+		return nil
+	}
+	cmd := exec.Command(clamscanPath, "--no-summary", sourcePath)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("file failed malware scan: %w", err)
+	}
+	return nil
+}